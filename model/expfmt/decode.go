@@ -0,0 +1,96 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Decoder decodes a stream of MetricFamily values out of one of the
+// supported exposition formats. Decode returns io.EOF once the stream is
+// exhausted.
+type Decoder interface {
+	Decode(*MetricFamily) error
+}
+
+// NewDecoder returns a Decoder that reads MetricFamilies from r in the
+// given format. FmtText and FmtOpenMetrics share an implementation, since
+// both use the same line-oriented sample syntax. Decoding FmtUnknown always
+// fails.
+func NewDecoder(r io.Reader, format Format) Decoder {
+	switch format {
+	case FmtText, FmtOpenMetrics:
+		return &textDecoder{r: r}
+	case FmtProtoDelim:
+		return &protoDelimDecoder{r: bufio.NewReader(r)}
+	default:
+		return &errorDecoder{err: fmt.Errorf("expfmt: unsupported encoding format %q", format)}
+	}
+}
+
+type errorDecoder struct{ err error }
+
+func (d *errorDecoder) Decode(*MetricFamily) error { return d.err }
+
+// textDecoder parses the whole stream on the first Decode call, since the
+// text and OpenMetrics formats require seeing every sample line to group
+// histogram buckets and summary quantiles into their MetricFamily.
+type textDecoder struct {
+	r      io.Reader
+	fams   []*MetricFamily
+	offset int
+}
+
+func (d *textDecoder) Decode(mf *MetricFamily) error {
+	if d.fams == nil {
+		fams, err := NewTextParser().TextToMetricFamilies(d.r)
+		if err != nil {
+			return err
+		}
+		d.fams = fams
+	}
+	if d.offset >= len(d.fams) {
+		return io.EOF
+	}
+	*mf = *d.fams[d.offset]
+	d.offset++
+	return nil
+}
+
+// protoDelimDecoder reads the varint-length-prefixed stream of
+// io.prometheus.client.MetricFamily protobuf messages written by
+// protoDelimEncoder.
+type protoDelimDecoder struct{ r *bufio.Reader }
+
+func (d *protoDelimDecoder) Decode(mf *MetricFamily) error {
+	length, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return err
+	}
+
+	decoded, err := decodeMetricFamily(buf)
+	if err != nil {
+		return err
+	}
+	*mf = *decoded
+	return nil
+}
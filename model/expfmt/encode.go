@@ -0,0 +1,114 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"fmt"
+	"io"
+)
+
+// Encoder encodes a stream of MetricFamily values into one of the supported
+// exposition formats. Implementations that need to emit a trailer once the
+// stream ends (currently only OpenMetrics' "# EOF" line) also implement
+// io.Closer; callers should always Close an Encoder obtained from
+// NewEncoder once they are done with it.
+type Encoder interface {
+	Encode(*MetricFamily) error
+}
+
+// EncoderOption configures an Encoder returned by NewEncoder.
+type EncoderOption func(*encoderOptions)
+
+type encoderOptions struct {
+	withCreated bool
+}
+
+// WithCreated instructs the OpenMetrics encoder to also emit the optional
+// "_created" series for counters, histograms and summaries that carry a
+// CreatedTimestamp. It has no effect on other formats.
+func WithCreated() EncoderOption {
+	return func(o *encoderOptions) { o.withCreated = true }
+}
+
+// NewEncoder returns an Encoder that writes MetricFamilies to w in the given
+// format. Encoding in FmtUnknown always fails.
+func NewEncoder(w io.Writer, format Format, options ...EncoderOption) Encoder {
+	var o encoderOptions
+	for _, opt := range options {
+		opt(&o)
+	}
+
+	switch format {
+	case FmtText:
+		return &textEncoder{w: w}
+	case FmtOpenMetrics:
+		return &openMetricsEncoder{w: w, withCreated: o.withCreated}
+	case FmtProtoDelim:
+		return &protoDelimEncoder{w: w}
+	default:
+		return &errorEncoder{err: fmt.Errorf("expfmt: unsupported encoding format %q", format)}
+	}
+}
+
+type errorEncoder struct{ err error }
+
+func (e *errorEncoder) Encode(*MetricFamily) error { return e.err }
+
+type textEncoder struct{ w io.Writer }
+
+func (e *textEncoder) Encode(mf *MetricFamily) error {
+	_, err := MetricFamilyToText(e.w, mf)
+	return err
+}
+
+type openMetricsEncoder struct {
+	w           io.Writer
+	withCreated bool
+}
+
+func (e *openMetricsEncoder) Encode(mf *MetricFamily) error {
+	_, err := MetricFamilyToOpenMetrics(e.w, mf, e.withCreated)
+	return err
+}
+
+// Close writes the terminating "# EOF" line mandated by the OpenMetrics
+// specification. It must be called exactly once, after the last
+// MetricFamily has been encoded.
+func (e *openMetricsEncoder) Close() error {
+	_, err := io.WriteString(e.w, "# EOF\n")
+	return err
+}
+
+// protoDelimEncoder writes each MetricFamily as an io.prometheus.client.
+// MetricFamily protobuf message (see proto.go), framed with a varint length
+// prefix per the "encoding=delimited" convention of FmtProtoDelim.
+type protoDelimEncoder struct{ w io.Writer }
+
+func (e *protoDelimEncoder) Encode(mf *MetricFamily) error {
+	return writeDelimited(e.w, mf)
+}
+
+func writeDelimited(w io.Writer, mf *MetricFamily) error {
+	msg, err := encodeMetricFamily(mf)
+	if err != nil {
+		return err
+	}
+
+	lenBuf := appendUvarint(nil, uint64(len(msg)))
+	if _, err := w.Write(lenBuf); err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	return err
+}
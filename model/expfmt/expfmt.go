@@ -0,0 +1,132 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Format is a Content-Type value for one of the supported exposition
+// formats.
+type Format string
+
+// Version numbers and media-type fragments used to build and recognize the
+// Format constants below.
+const (
+	TextVersion        = "0.0.4"
+	OpenMetricsVersion = "1.0.0"
+
+	// ProtoType and ProtoProtocol identify the official Prometheus protobuf
+	// exposition format (io.prometheus.client.MetricFamily). This package
+	// has no generated *.pb.go for it; proto.go implements just enough of
+	// the protobuf wire format by hand, against that message's stable,
+	// public field numbers, to produce and consume real
+	// application/vnd.google.protobuf payloads without a codegen step.
+	ProtoType     = `application/vnd.google.protobuf`
+	ProtoProtocol = `io.prometheus.client.MetricFamily`
+	ProtoFmt      = ProtoType + "; proto=" + ProtoProtocol + ";"
+)
+
+// The supported exposition formats.
+const (
+	FmtUnknown     Format = `<unknown>`
+	FmtText        Format = `text/plain; version=` + TextVersion + `; charset=utf-8`
+	FmtProtoDelim  Format = ProtoFmt + ` encoding=delimited`
+	FmtOpenMetrics Format = `application/openmetrics-text; version=` + OpenMetricsVersion + `; charset=utf-8`
+)
+
+const (
+	hdrContentType = "Content-Type"
+)
+
+// ResponseFormat extracts the correct Format from a scrape response's
+// Content-Type header. It returns FmtUnknown if the header is missing,
+// malformed, or names an unsupported format.
+func ResponseFormat(h http.Header) Format {
+	ct := h.Get(hdrContentType)
+
+	mediatype, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return FmtUnknown
+	}
+
+	switch mediatype {
+	case ProtoType:
+		if p, ok := params["proto"]; ok && p != ProtoProtocol {
+			return FmtUnknown
+		}
+		if params["encoding"] != "delimited" {
+			return FmtUnknown
+		}
+		return FmtProtoDelim
+
+	case "text/plain":
+		if v, ok := params["version"]; ok && v != TextVersion {
+			return FmtUnknown
+		}
+		return FmtText
+
+	case "application/openmetrics-text":
+		if v, ok := params["version"]; ok && v != OpenMetricsVersion {
+			return FmtUnknown
+		}
+		return FmtOpenMetrics
+	}
+
+	return FmtUnknown
+}
+
+// NegotiateFormat returns the Format a server should respond with, given the
+// value of a request's Accept header. It picks the first supported format
+// named in accept, in the order the client listed them, and falls back to
+// FmtText (the most widely understood format) if accept is empty or names
+// nothing this package supports.
+func NegotiateFormat(accept string) Format {
+	if strings.TrimSpace(accept) == "" {
+		return FmtText
+	}
+
+	for _, ac := range strings.Split(accept, ",") {
+		mediatype, params, err := mime.ParseMediaType(strings.TrimSpace(ac))
+		if err != nil {
+			continue
+		}
+
+		switch mediatype {
+		case ProtoType:
+			if params["proto"] != ProtoProtocol {
+				continue
+			}
+			if params["encoding"] == "delimited" {
+				return FmtProtoDelim
+			}
+		case "application/openmetrics-text":
+			if v, ok := params["version"]; ok && v != OpenMetricsVersion {
+				continue
+			}
+			return FmtOpenMetrics
+		case "text/plain":
+			if v, ok := params["version"]; ok && v != TextVersion {
+				continue
+			}
+			return FmtText
+		case "*/*":
+			return FmtText
+		}
+	}
+
+	return FmtText
+}
@@ -0,0 +1,255 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func float64p(f float64) *float64 { return &f }
+
+func TestTextRoundTrip(t *testing.T) {
+	mf := &MetricFamily{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests.",
+		Type: MetricTypeCounter,
+		Metric: []*Metric{
+			{Label: []LabelPair{{Name: "method", Value: "GET"}, {Name: "code", Value: "200"}}, Counter: float64p(42)},
+			{Label: []LabelPair{{Name: "method", Value: "POST"}, {Name: "code", Value: "500"}}, Counter: float64p(3)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if _, err := MetricFamilyToText(&buf, mf); err != nil {
+		t.Fatalf("MetricFamilyToText: %v", err)
+	}
+
+	fams, err := NewTextParser().TextToMetricFamilies(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("TextToMetricFamilies: %v", err)
+	}
+	if len(fams) != 1 {
+		t.Fatalf("got %d families, want 1", len(fams))
+	}
+
+	got := fams[0]
+	if got.Name != mf.Name || got.Help != mf.Help || got.Type != mf.Type {
+		t.Fatalf("got %+v, want name/help/type to match %+v", got, mf)
+	}
+	if len(got.Metric) != 2 {
+		t.Fatalf("got %d metrics, want 2", len(got.Metric))
+	}
+	for _, m := range got.Metric {
+		if m.Counter == nil {
+			t.Fatalf("metric %+v has no Counter value", m)
+		}
+	}
+}
+
+func TestTextParserMergesLabelsWrittenInDifferentOrder(t *testing.T) {
+	input := `up{job="a",instance="1"} 1
+up{instance="1",job="a"} 2
+`
+	fams, err := NewTextParser().TextToMetricFamilies(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("TextToMetricFamilies: %v", err)
+	}
+	if len(fams) != 1 {
+		t.Fatalf("got %d families, want 1", len(fams))
+	}
+	// The second line should have overwritten the first sample's value on
+	// the same series, not created a second one.
+	if len(fams[0].Metric) != 1 {
+		t.Fatalf("got %d metrics, want 1 (labels differ only in order)", len(fams[0].Metric))
+	}
+}
+
+func TestTextParserHistogram(t *testing.T) {
+	input := `# HELP req_duration_seconds request duration
+# TYPE req_duration_seconds histogram
+req_duration_seconds_bucket{le="0.1"} 5
+req_duration_seconds_bucket{le="0.5"} 9
+req_duration_seconds_bucket{le="+Inf"} 10
+req_duration_seconds_sum 3.2
+req_duration_seconds_count 10
+`
+	fams, err := NewTextParser().TextToMetricFamilies(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("TextToMetricFamilies: %v", err)
+	}
+	if len(fams) != 1 {
+		t.Fatalf("got %d families, want 1", len(fams))
+	}
+	fam := fams[0]
+	if fam.Type != MetricTypeHistogram {
+		t.Fatalf("type = %v, want histogram", fam.Type)
+	}
+	if len(fam.Metric) != 1 {
+		t.Fatalf("got %d metrics, want 1", len(fam.Metric))
+	}
+	h := fam.Metric[0].Histogram
+	if h == nil {
+		t.Fatal("metric has no Histogram")
+	}
+	if len(h.Bucket) != 3 || h.SampleCount != 10 || h.SampleSum != 3.2 {
+		t.Fatalf("unexpected histogram: %+v", h)
+	}
+}
+
+func TestOpenMetricsEncoderEmitsCreatedForCounter(t *testing.T) {
+	created := 12345.0
+	mf := &MetricFamily{
+		Name: "events",
+		Type: MetricTypeCounter,
+		Metric: []*Metric{
+			{Counter: float64p(1), CounterCreatedTimestamp: &created},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, FmtOpenMetrics, WithCreated())
+	if err := enc.Encode(mf); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "events_total ") {
+		t.Fatalf("missing counter line suffixed with _total: %q", out)
+	}
+	if !strings.Contains(out, "events_created 12345") {
+		t.Fatalf("missing _created line suffixed to the bare family name: %q", out)
+	}
+	if strings.Contains(out, "events_total_created") {
+		t.Fatalf("_created line must not carry the _total suffix: %q", out)
+	}
+}
+
+func TestEncodeReturnsErrorInsteadOfPanickingOnTypeMismatch(t *testing.T) {
+	mf := &MetricFamily{
+		Name:   "bad",
+		Type:   MetricTypeHistogram,
+		Metric: []*Metric{{}}, // no Histogram set, despite the declared Type
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, FmtText).Encode(mf); err == nil {
+		t.Fatal("expected an error for a Histogram-typed Metric with no Histogram value, got nil")
+	}
+
+	buf.Reset()
+	if err := NewEncoder(&buf, FmtOpenMetrics).Encode(mf); err == nil {
+		t.Fatal("expected an error for a Histogram-typed Metric with no Histogram value, got nil")
+	}
+}
+
+func TestProtoDelimRoundTrip(t *testing.T) {
+	mf := &MetricFamily{
+		Name: "foo",
+		Help: "a help string",
+		Type: MetricTypeHistogram,
+		Metric: []*Metric{{
+			Label: []LabelPair{{Name: "job", Value: "api"}},
+			Histogram: &Histogram{
+				SampleCount: 10,
+				SampleSum:   3.2,
+				Bucket: []Bucket{
+					{UpperBound: 0.5, CumulativeCount: 4},
+					{UpperBound: 1, CumulativeCount: 10},
+				},
+			},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, FmtProtoDelim).Encode(mf); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var got MetricFamily
+	if err := NewDecoder(&buf, FmtProtoDelim).Decode(&got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Name != mf.Name || got.Help != mf.Help || got.Type != mf.Type {
+		t.Fatalf("got %+v, want name/help/type to match %+v", got, mf)
+	}
+	if len(got.Metric) != 1 || got.Metric[0].Histogram == nil {
+		t.Fatalf("got %+v, want one histogram metric", got)
+	}
+	gh := got.Metric[0].Histogram
+	if gh.SampleCount != 10 || gh.SampleSum != 3.2 || len(gh.Bucket) != 2 {
+		t.Fatalf("got %+v, want a round trip of %+v", gh, mf.Metric[0].Histogram)
+	}
+	if len(got.Metric[0].Label) != 1 || got.Metric[0].Label[0] != (LabelPair{Name: "job", Value: "api"}) {
+		t.Fatalf("got labels %+v, want %+v", got.Metric[0].Label, mf.Metric[0].Label)
+	}
+}
+
+func TestProtoDelimRoundTripMultipleFamilies(t *testing.T) {
+	families := []*MetricFamily{
+		{Name: "a", Type: MetricTypeCounter, Metric: []*Metric{{Counter: float64p(1)}}},
+		{Name: "b", Type: MetricTypeGauge, Metric: []*Metric{{Gauge: float64p(2)}}},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, FmtProtoDelim)
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+	}
+
+	dec := NewDecoder(&buf, FmtProtoDelim)
+	for i, want := range families {
+		var got MetricFamily
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("Decode message %d: %v", i, err)
+		}
+		if got.Name != want.Name || got.Type != want.Type {
+			t.Fatalf("message %d: got %+v, want %+v", i, got, want)
+		}
+	}
+	var trailing MetricFamily
+	if err := dec.Decode(&trailing); err == nil {
+		t.Fatal("expected io.EOF after the last message")
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   Format
+	}{
+		{"", FmtText},
+		{"text/plain", FmtText},
+		{"application/openmetrics-text", FmtOpenMetrics},
+		{ProtoType + "; proto=" + ProtoProtocol + "; encoding=delimited", FmtProtoDelim},
+		{"application/json", FmtText},
+	}
+	for _, c := range cases {
+		if got := NegotiateFormat(c.accept); got != c.want {
+			t.Errorf("NegotiateFormat(%q) = %q, want %q", c.accept, got, c.want)
+		}
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", string(FmtOpenMetrics))
+	if got := ResponseFormat(h); got != FmtOpenMetrics {
+		t.Errorf("ResponseFormat = %q, want %q", got, FmtOpenMetrics)
+	}
+}
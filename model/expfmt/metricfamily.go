@@ -0,0 +1,125 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expfmt contains tools for reading and writing Prometheus metrics
+// in the text, OpenMetrics and delimited protobuf (see ProtoType) exposition
+// formats.
+package expfmt
+
+// MetricType describes the type of a MetricFamily, following the Prometheus
+// exposition format vocabulary. It is a distinct type from model.MetricType
+// (model/metricfamily.go): this one describes a wire-format MetricFamily
+// built for encoding/decoding exposition streams, while model.MetricFamily
+// describes one reassembled in-memory from a flat model.Sample stream (see
+// model.GroupByFamily). The two share an ordinal order (Untyped is always
+// zero) but are otherwise not interchangeable.
+type MetricType int
+
+// Valid values for MetricType.
+const (
+	MetricTypeUntyped MetricType = iota
+	MetricTypeCounter
+	MetricTypeGauge
+	MetricTypeHistogram
+	MetricTypeGaugeHistogram
+	MetricTypeSummary
+)
+
+// String returns the lower-case name of the type as used in "# TYPE" lines.
+func (t MetricType) String() string {
+	switch t {
+	case MetricTypeCounter:
+		return "counter"
+	case MetricTypeGauge:
+		return "gauge"
+	case MetricTypeHistogram:
+		return "histogram"
+	case MetricTypeGaugeHistogram:
+		return "gaugehistogram"
+	case MetricTypeSummary:
+		return "summary"
+	default:
+		return "untyped"
+	}
+}
+
+// LabelPair is a name/value pair attached to a Metric.
+type LabelPair struct {
+	Name  string
+	Value string
+}
+
+// Bucket is a cumulative histogram bucket.
+type Bucket struct {
+	UpperBound      float64
+	CumulativeCount uint64
+}
+
+// Quantile is a single summary quantile observation.
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// Histogram holds the buckets, sum and count for a single histogram sample.
+type Histogram struct {
+	SampleCount uint64
+	SampleSum   float64
+	Bucket      []Bucket
+	// CreatedTimestamp is the Unix timestamp, in seconds, at which the
+	// underlying counter was created. It is only ever set or consumed in
+	// the OpenMetrics format, where it is exposed as a "_created" series.
+	CreatedTimestamp *float64
+}
+
+// Summary holds the quantiles, sum and count for a single summary sample.
+type Summary struct {
+	SampleCount uint64
+	SampleSum   float64
+	Quantile    []Quantile
+	// CreatedTimestamp mirrors Histogram.CreatedTimestamp.
+	CreatedTimestamp *float64
+}
+
+// Metric is one sample (in the histogram/summary case, one group of
+// related samples) belonging to a MetricFamily.
+type Metric struct {
+	Label []LabelPair
+	// TimestampMs is the sample timestamp in milliseconds since the Unix
+	// epoch. A nil value means "no explicit timestamp".
+	TimestampMs *int64
+
+	// Exactly one of the following is set, depending on the MetricType of
+	// the enclosing MetricFamily.
+	Counter   *float64
+	Gauge     *float64
+	Untyped   *float64
+	Histogram *Histogram
+	Summary   *Summary
+
+	// CounterCreatedTimestamp is the Unix timestamp, in seconds, at which
+	// a Counter metric was created. Like Histogram.CreatedTimestamp and
+	// Summary.CreatedTimestamp, it is only ever set or consumed in the
+	// OpenMetrics format, where it is exposed as a "_created" series; it
+	// is ignored for every other MetricType.
+	CounterCreatedTimestamp *float64
+}
+
+// MetricFamily is a named, typed, documented collection of Metrics that
+// share the same metric name.
+type MetricFamily struct {
+	Name   string
+	Help   string
+	Type   MetricType
+	Metric []*Metric
+}
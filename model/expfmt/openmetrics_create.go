@@ -0,0 +1,162 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MetricFamilyToOpenMetrics writes mf to w in the OpenMetrics 1.0 text
+// format and returns the number of bytes written. Unlike the legacy text
+// format, counters are suffixed with "_total" and, if withCreated is true,
+// counters, histograms and summaries that carry a CreatedTimestamp also
+// emit a "_created" series. Callers are responsible for writing the final
+// "# EOF" line once all MetricFamilies have been encoded; see Encoder.
+func MetricFamilyToOpenMetrics(w io.Writer, mf *MetricFamily, withCreated bool) (int, error) {
+	if mf.Name == "" {
+		return 0, fmt.Errorf("expfmt: MetricFamily has no name")
+	}
+
+	name := mf.Name
+	if mf.Type == MetricTypeCounter && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+
+	var written int
+
+	if mf.Help != "" {
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(mf.Help))
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	n, err := fmt.Fprintf(w, "# TYPE %s %s\n", name, mf.Type)
+	written += n
+	if err != nil {
+		return written, err
+	}
+
+	for _, m := range mf.Metric {
+		n, err := writeOpenMetricsMetric(w, mf, name, m, withCreated)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+func writeOpenMetricsMetric(w io.Writer, mf *MetricFamily, name string, m *Metric, withCreated bool) (int, error) {
+	var written int
+
+	switch mf.Type {
+	case MetricTypeCounter:
+		if m.Counter == nil {
+			return 0, fmt.Errorf("expfmt: counter metric %q has no Counter value", mf.Name)
+		}
+		n, err := writeTextSample(w, name, "", m.Label, m.Counter, m.TimestampMs)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if withCreated && m.CounterCreatedTimestamp != nil {
+			// "_created" is suffixed to the bare family name (mf.Name),
+			// not to name, which already carries the "_total" suffix.
+			n, err = writeTextSample(w, mf.Name, "_created", m.Label, m.CounterCreatedTimestamp, m.TimestampMs)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+	case MetricTypeGauge:
+		if m.Gauge == nil {
+			return 0, fmt.Errorf("expfmt: gauge metric %q has no Gauge value", mf.Name)
+		}
+		n, err := writeTextSample(w, name, "", m.Label, m.Gauge, m.TimestampMs)
+		return n, err
+	case MetricTypeUntyped:
+		if m.Untyped == nil {
+			return 0, fmt.Errorf("expfmt: untyped metric %q has no Untyped value", mf.Name)
+		}
+		n, err := writeTextSample(w, name, "", m.Label, m.Untyped, m.TimestampMs)
+		return n, err
+	case MetricTypeHistogram, MetricTypeGaugeHistogram:
+		h := m.Histogram
+		if h == nil {
+			return 0, fmt.Errorf("expfmt: histogram metric %q has no Histogram value", mf.Name)
+		}
+		for _, b := range h.Bucket {
+			n, err := writeTextSample(w, name, "_bucket", appendLabel(m.Label, "le", formatFloat(b.UpperBound)), float64Ptr(float64(b.CumulativeCount)), m.TimestampMs)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+		n, err := writeTextSample(w, name, "_sum", m.Label, &h.SampleSum, m.TimestampMs)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		n, err = writeTextSample(w, name, "_count", m.Label, float64Ptr(float64(h.SampleCount)), m.TimestampMs)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if withCreated && h.CreatedTimestamp != nil {
+			n, err = writeTextSample(w, name, "_created", m.Label, h.CreatedTimestamp, m.TimestampMs)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+	case MetricTypeSummary:
+		s := m.Summary
+		if s == nil {
+			return 0, fmt.Errorf("expfmt: summary metric %q has no Summary value", mf.Name)
+		}
+		for _, q := range s.Quantile {
+			n, err := writeTextSample(w, name, "", appendLabel(m.Label, "quantile", formatFloat(q.Quantile)), &q.Value, m.TimestampMs)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+		n, err := writeTextSample(w, name, "_sum", m.Label, &s.SampleSum, m.TimestampMs)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		n, err = writeTextSample(w, name, "_count", m.Label, float64Ptr(float64(s.SampleCount)), m.TimestampMs)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if withCreated && s.CreatedTimestamp != nil {
+			n, err = writeTextSample(w, name, "_created", m.Label, s.CreatedTimestamp, m.TimestampMs)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+	default:
+		return written, fmt.Errorf("expfmt: unknown metric type %v", mf.Type)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,537 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"fmt"
+	"math"
+)
+
+// This file implements the wire format of io.prometheus.client.MetricFamily
+// (see https://github.com/prometheus/client_model/blob/master/metrics.proto),
+// the message used by FmtProtoDelim, by hand: field numbers and wire types
+// below are taken directly from that stable, public schema and written or
+// read with the protobuf wire encoding directly, without depending on
+// google.golang.org/protobuf or any generated *.pb.go. This keeps the
+// delimited format actually interoperable with the wider Prometheus
+// ecosystem (client_golang, Pushgateway, remote write/read, federation)
+// while this module has no code generation step of its own.
+
+// Field numbers from client_model/metrics.proto.
+const (
+	pbLabelPairName  = 1
+	pbLabelPairValue = 2
+
+	pbDoubleValueValue = 1 // shared by Gauge, Counter and Untyped
+
+	pbQuantileQuantile = 1
+	pbQuantileValue    = 2
+
+	pbSummarySampleCount = 1
+	pbSummarySampleSum   = 2
+	pbSummaryQuantile    = 3
+
+	pbBucketCumulativeCount = 1
+	pbBucketUpperBound      = 2
+
+	pbHistogramSampleCount = 1
+	pbHistogramSampleSum   = 2
+	pbHistogramBucket      = 3
+
+	pbMetricLabel       = 1
+	pbMetricGauge       = 2
+	pbMetricCounter     = 3
+	pbMetricSummary     = 4
+	pbMetricUntyped     = 5
+	pbMetricTimestampMs = 6
+	pbMetricHistogram   = 7
+
+	pbMetricFamilyName   = 1
+	pbMetricFamilyHelp   = 2
+	pbMetricFamilyType   = 3
+	pbMetricFamilyMetric = 4
+)
+
+// io.prometheus.client.MetricType ordinals. These do not match our own
+// MetricType's ordinals (see the MetricType doc comment in metricfamily.go),
+// so encoding/decoding always goes through wireMetricType/metricTypeFromWire
+// rather than a bare numeric cast.
+const (
+	wireCounter        = 0
+	wireGauge          = 1
+	wireSummary        = 2
+	wireUntyped        = 3
+	wireHistogram      = 4
+	wireGaugeHistogram = 5
+)
+
+func wireMetricType(t MetricType) (uint64, error) {
+	switch t {
+	case MetricTypeCounter:
+		return wireCounter, nil
+	case MetricTypeGauge:
+		return wireGauge, nil
+	case MetricTypeSummary:
+		return wireSummary, nil
+	case MetricTypeUntyped:
+		return wireUntyped, nil
+	case MetricTypeHistogram:
+		return wireHistogram, nil
+	case MetricTypeGaugeHistogram:
+		return wireGaugeHistogram, nil
+	default:
+		return 0, fmt.Errorf("expfmt: unknown metric type %v", t)
+	}
+}
+
+func metricTypeFromWire(v uint64) (MetricType, error) {
+	switch v {
+	case wireCounter:
+		return MetricTypeCounter, nil
+	case wireGauge:
+		return MetricTypeGauge, nil
+	case wireSummary:
+		return MetricTypeSummary, nil
+	case wireUntyped:
+		return MetricTypeUntyped, nil
+	case wireHistogram:
+		return MetricTypeHistogram, nil
+	case wireGaugeHistogram:
+		return MetricTypeGaugeHistogram, nil
+	default:
+		return 0, fmt.Errorf("expfmt: unknown wire metric type %d", v)
+	}
+}
+
+// Protobuf wire types (field tag = field-number<<3 | wireType).
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendTag(buf []byte, field int, wireType int) []byte {
+	return appendUvarint(buf, uint64(field)<<3|uint64(wireType))
+}
+
+// appendVarint appends field as a varint, omitting it entirely if v is zero
+// (the proto3 default, recovered as zero on decode when the field is
+// simply absent).
+func appendVarint(buf []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = appendTag(buf, field, wireVarint)
+	return appendUvarint(buf, v)
+}
+
+// appendVarintAlways appends field as a varint unconditionally, for fields
+// whose zero value is still meaningful (MetricFamily.Type, Metric.TimestampMs).
+func appendVarintAlways(buf []byte, field int, v uint64) []byte {
+	buf = appendTag(buf, field, wireVarint)
+	return appendUvarint(buf, v)
+}
+
+func appendDouble(buf []byte, field int, v float64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = appendTag(buf, field, wireFixed64)
+	bits := math.Float64bits(v)
+	for i := 0; i < 8; i++ {
+		buf = append(buf, byte(bits>>(8*i)))
+	}
+	return buf
+}
+
+func appendString(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = appendTag(buf, field, wireBytes)
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendMessage(buf []byte, field int, msg []byte) []byte {
+	buf = appendTag(buf, field, wireBytes)
+	buf = appendUvarint(buf, uint64(len(msg)))
+	return append(buf, msg...)
+}
+
+func encodeLabelPair(lp LabelPair) []byte {
+	var buf []byte
+	buf = appendString(buf, pbLabelPairName, lp.Name)
+	buf = appendString(buf, pbLabelPairValue, lp.Value)
+	return buf
+}
+
+func encodeDoubleValue(v float64) []byte {
+	return appendDouble(nil, pbDoubleValueValue, v)
+}
+
+func encodeQuantile(q Quantile) []byte {
+	var buf []byte
+	buf = appendDouble(buf, pbQuantileQuantile, q.Quantile)
+	buf = appendDouble(buf, pbQuantileValue, q.Value)
+	return buf
+}
+
+func encodeSummary(s *Summary) []byte {
+	var buf []byte
+	buf = appendVarint(buf, pbSummarySampleCount, s.SampleCount)
+	buf = appendDouble(buf, pbSummarySampleSum, s.SampleSum)
+	for _, q := range s.Quantile {
+		buf = appendMessage(buf, pbSummaryQuantile, encodeQuantile(q))
+	}
+	return buf
+}
+
+func encodeBucket(b Bucket) []byte {
+	var buf []byte
+	buf = appendVarint(buf, pbBucketCumulativeCount, b.CumulativeCount)
+	buf = appendDouble(buf, pbBucketUpperBound, b.UpperBound)
+	return buf
+}
+
+func encodeHistogram(h *Histogram) []byte {
+	var buf []byte
+	buf = appendVarint(buf, pbHistogramSampleCount, h.SampleCount)
+	buf = appendDouble(buf, pbHistogramSampleSum, h.SampleSum)
+	for _, b := range h.Bucket {
+		buf = appendMessage(buf, pbHistogramBucket, encodeBucket(b))
+	}
+	return buf
+}
+
+func encodeMetric(m *Metric) []byte {
+	var buf []byte
+	for _, lp := range m.Label {
+		buf = appendMessage(buf, pbMetricLabel, encodeLabelPair(lp))
+	}
+	if m.Gauge != nil {
+		buf = appendMessage(buf, pbMetricGauge, encodeDoubleValue(*m.Gauge))
+	}
+	if m.Counter != nil {
+		buf = appendMessage(buf, pbMetricCounter, encodeDoubleValue(*m.Counter))
+	}
+	if m.Summary != nil {
+		buf = appendMessage(buf, pbMetricSummary, encodeSummary(m.Summary))
+	}
+	if m.Untyped != nil {
+		buf = appendMessage(buf, pbMetricUntyped, encodeDoubleValue(*m.Untyped))
+	}
+	if m.Histogram != nil {
+		buf = appendMessage(buf, pbMetricHistogram, encodeHistogram(m.Histogram))
+	}
+	if m.TimestampMs != nil {
+		buf = appendVarintAlways(buf, pbMetricTimestampMs, uint64(*m.TimestampMs))
+	}
+	return buf
+}
+
+// encodeMetricFamily returns mf encoded as an io.prometheus.client.MetricFamily
+// protobuf message.
+func encodeMetricFamily(mf *MetricFamily) ([]byte, error) {
+	wt, err := wireMetricType(mf.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	buf = appendString(buf, pbMetricFamilyName, mf.Name)
+	buf = appendString(buf, pbMetricFamilyHelp, mf.Help)
+	buf = appendVarintAlways(buf, pbMetricFamilyType, wt)
+	for _, m := range mf.Metric {
+		buf = appendMessage(buf, pbMetricFamilyMetric, encodeMetric(m))
+	}
+	return buf, nil
+}
+
+// protoField is one decoded (field number, wire type, payload) triple from
+// a protobuf byte stream.
+type protoField struct {
+	num  int
+	wire int
+	u64  uint64 // populated for wireVarint and wireFixed64 (raw bits)
+	buf  []byte // populated for wireBytes
+}
+
+// decodeFields walks buf and returns every top-level field it describes.
+// Per the protobuf wire format's forward-compatibility rules, callers
+// simply ignore any field number they don't recognize.
+func decodeFields(buf []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(buf) > 0 {
+		tag, n, err := readUvarint(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[n:]
+		num := int(tag >> 3)
+		wt := int(tag & 7)
+
+		switch wt {
+		case wireVarint:
+			v, n, err := readUvarint(buf)
+			if err != nil {
+				return nil, err
+			}
+			buf = buf[n:]
+			fields = append(fields, protoField{num: num, wire: wt, u64: v})
+		case wireFixed64:
+			if len(buf) < 8 {
+				return nil, fmt.Errorf("expfmt: truncated fixed64 field %d", num)
+			}
+			var v uint64
+			for i := 0; i < 8; i++ {
+				v |= uint64(buf[i]) << (8 * i)
+			}
+			fields = append(fields, protoField{num: num, wire: wt, u64: v})
+			buf = buf[8:]
+		case wireBytes:
+			l, n, err := readUvarint(buf)
+			if err != nil {
+				return nil, err
+			}
+			buf = buf[n:]
+			if uint64(len(buf)) < l {
+				return nil, fmt.Errorf("expfmt: truncated bytes field %d", num)
+			}
+			fields = append(fields, protoField{num: num, wire: wt, buf: buf[:l]})
+			buf = buf[l:]
+		default:
+			return nil, fmt.Errorf("expfmt: unsupported wire type %d for field %d", wt, num)
+		}
+	}
+	return fields, nil
+}
+
+func readUvarint(buf []byte) (uint64, int, error) {
+	var v uint64
+	var shift uint
+	for i, b := range buf {
+		if i >= 10 {
+			return 0, 0, fmt.Errorf("expfmt: varint too long")
+		}
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, i + 1, nil
+		}
+		shift += 7
+	}
+	return 0, 0, fmt.Errorf("expfmt: truncated varint")
+}
+
+func decodeLabelPair(buf []byte) (LabelPair, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return LabelPair{}, err
+	}
+	var lp LabelPair
+	for _, f := range fields {
+		switch f.num {
+		case pbLabelPairName:
+			lp.Name = string(f.buf)
+		case pbLabelPairValue:
+			lp.Value = string(f.buf)
+		}
+	}
+	return lp, nil
+}
+
+func decodeDoubleValue(buf []byte) (float64, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return 0, err
+	}
+	var v float64
+	for _, f := range fields {
+		if f.num == pbDoubleValueValue {
+			v = math.Float64frombits(f.u64)
+		}
+	}
+	return v, nil
+}
+
+func decodeQuantile(buf []byte) (Quantile, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return Quantile{}, err
+	}
+	var q Quantile
+	for _, f := range fields {
+		switch f.num {
+		case pbQuantileQuantile:
+			q.Quantile = math.Float64frombits(f.u64)
+		case pbQuantileValue:
+			q.Value = math.Float64frombits(f.u64)
+		}
+	}
+	return q, nil
+}
+
+func decodeSummary(buf []byte) (*Summary, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return nil, err
+	}
+	s := &Summary{}
+	for _, f := range fields {
+		switch f.num {
+		case pbSummarySampleCount:
+			s.SampleCount = f.u64
+		case pbSummarySampleSum:
+			s.SampleSum = math.Float64frombits(f.u64)
+		case pbSummaryQuantile:
+			q, err := decodeQuantile(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			s.Quantile = append(s.Quantile, q)
+		}
+	}
+	return s, nil
+}
+
+func decodeBucket(buf []byte) (Bucket, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return Bucket{}, err
+	}
+	var b Bucket
+	for _, f := range fields {
+		switch f.num {
+		case pbBucketCumulativeCount:
+			b.CumulativeCount = f.u64
+		case pbBucketUpperBound:
+			b.UpperBound = math.Float64frombits(f.u64)
+		}
+	}
+	return b, nil
+}
+
+func decodeHistogram(buf []byte) (*Histogram, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return nil, err
+	}
+	h := &Histogram{}
+	for _, f := range fields {
+		switch f.num {
+		case pbHistogramSampleCount:
+			h.SampleCount = f.u64
+		case pbHistogramSampleSum:
+			h.SampleSum = math.Float64frombits(f.u64)
+		case pbHistogramBucket:
+			b, err := decodeBucket(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			h.Bucket = append(h.Bucket, b)
+		}
+	}
+	return h, nil
+}
+
+func decodeMetric(buf []byte) (*Metric, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metric{}
+	for _, f := range fields {
+		switch f.num {
+		case pbMetricLabel:
+			lp, err := decodeLabelPair(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			m.Label = append(m.Label, lp)
+		case pbMetricGauge:
+			v, err := decodeDoubleValue(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			m.Gauge = &v
+		case pbMetricCounter:
+			v, err := decodeDoubleValue(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			m.Counter = &v
+		case pbMetricSummary:
+			s, err := decodeSummary(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			m.Summary = s
+		case pbMetricUntyped:
+			v, err := decodeDoubleValue(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			m.Untyped = &v
+		case pbMetricHistogram:
+			h, err := decodeHistogram(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			m.Histogram = h
+		case pbMetricTimestampMs:
+			ts := int64(f.u64)
+			m.TimestampMs = &ts
+		}
+	}
+	return m, nil
+}
+
+// decodeMetricFamily parses buf as an io.prometheus.client.MetricFamily
+// protobuf message.
+func decodeMetricFamily(buf []byte) (*MetricFamily, error) {
+	fields, err := decodeFields(buf)
+	if err != nil {
+		return nil, err
+	}
+	mf := &MetricFamily{}
+	for _, f := range fields {
+		switch f.num {
+		case pbMetricFamilyName:
+			mf.Name = string(f.buf)
+		case pbMetricFamilyHelp:
+			mf.Help = string(f.buf)
+		case pbMetricFamilyType:
+			t, err := metricTypeFromWire(f.u64)
+			if err != nil {
+				return nil, err
+			}
+			mf.Type = t
+		case pbMetricFamilyMetric:
+			m, err := decodeMetric(f.buf)
+			if err != nil {
+				return nil, err
+			}
+			mf.Metric = append(mf.Metric, m)
+		}
+	}
+	return mf, nil
+}
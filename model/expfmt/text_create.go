@@ -0,0 +1,174 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MetricFamilyToText writes mf to w in the Prometheus text exposition
+// format (version 0.0.4) and returns the number of bytes written.
+func MetricFamilyToText(w io.Writer, mf *MetricFamily) (int, error) {
+	if mf.Name == "" {
+		return 0, fmt.Errorf("expfmt: MetricFamily has no name")
+	}
+
+	var written int
+
+	if mf.Help != "" {
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n", mf.Name, escapeHelp(mf.Help))
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	n, err := fmt.Fprintf(w, "# TYPE %s %s\n", mf.Name, mf.Type)
+	written += n
+	if err != nil {
+		return written, err
+	}
+
+	for _, m := range mf.Metric {
+		n, err := writeTextMetric(w, mf, m)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+func writeTextMetric(w io.Writer, mf *MetricFamily, m *Metric) (int, error) {
+	var written int
+
+	switch mf.Type {
+	case MetricTypeCounter:
+		if m.Counter == nil {
+			return 0, fmt.Errorf("expfmt: counter metric %q has no Counter value", mf.Name)
+		}
+		n, err := writeTextSample(w, mf.Name, "", m.Label, m.Counter, m.TimestampMs)
+		return n, err
+	case MetricTypeGauge:
+		if m.Gauge == nil {
+			return 0, fmt.Errorf("expfmt: gauge metric %q has no Gauge value", mf.Name)
+		}
+		n, err := writeTextSample(w, mf.Name, "", m.Label, m.Gauge, m.TimestampMs)
+		return n, err
+	case MetricTypeUntyped:
+		if m.Untyped == nil {
+			return 0, fmt.Errorf("expfmt: untyped metric %q has no Untyped value", mf.Name)
+		}
+		n, err := writeTextSample(w, mf.Name, "", m.Label, m.Untyped, m.TimestampMs)
+		return n, err
+	case MetricTypeHistogram:
+		h := m.Histogram
+		if h == nil {
+			return 0, fmt.Errorf("expfmt: histogram metric %q has no Histogram value", mf.Name)
+		}
+		for _, b := range h.Bucket {
+			n, err := writeTextSample(w, mf.Name, "_bucket", appendLabel(m.Label, "le", formatFloat(b.UpperBound)), float64Ptr(float64(b.CumulativeCount)), m.TimestampMs)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+		n, err := writeTextSample(w, mf.Name, "_sum", m.Label, &h.SampleSum, m.TimestampMs)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		n, err = writeTextSample(w, mf.Name, "_count", m.Label, float64Ptr(float64(h.SampleCount)), m.TimestampMs)
+		written += n
+		return written, err
+	case MetricTypeSummary:
+		s := m.Summary
+		if s == nil {
+			return 0, fmt.Errorf("expfmt: summary metric %q has no Summary value", mf.Name)
+		}
+		for _, q := range s.Quantile {
+			n, err := writeTextSample(w, mf.Name, "", appendLabel(m.Label, "quantile", formatFloat(q.Quantile)), &q.Value, m.TimestampMs)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+		n, err := writeTextSample(w, mf.Name, "_sum", m.Label, &s.SampleSum, m.TimestampMs)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		n, err = writeTextSample(w, mf.Name, "_count", m.Label, float64Ptr(float64(s.SampleCount)), m.TimestampMs)
+		written += n
+		return written, err
+	default:
+		return 0, fmt.Errorf("expfmt: unknown metric type %v", mf.Type)
+	}
+}
+
+func writeTextSample(w io.Writer, name, suffix string, labels []LabelPair, value *float64, timestampMs *int64) (int, error) {
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteString(suffix)
+	if len(labels) > 0 {
+		sb.WriteByte('{')
+		for i, lp := range labels {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(lp.Name)
+			sb.WriteString(`="`)
+			sb.WriteString(escapeLabelValue(lp.Value))
+			sb.WriteByte('"')
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(formatFloat(*value))
+	if timestampMs != nil {
+		sb.WriteByte(' ')
+		sb.WriteString(strconv.FormatInt(*timestampMs, 10))
+	}
+	sb.WriteByte('\n')
+	return io.WriteString(w, sb.String())
+}
+
+func appendLabel(labels []LabelPair, name, value string) []LabelPair {
+	out := make([]LabelPair, len(labels), len(labels)+1)
+	copy(out, labels)
+	return append(out, LabelPair{Name: name, Value: value})
+}
+
+func float64Ptr(f float64) *float64 { return &f }
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+func escapeLabelValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
@@ -0,0 +1,404 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expfmt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TextParser parses a stream in the Prometheus text (and, since both share
+// the same line-oriented sample syntax, OpenMetrics) exposition format into
+// MetricFamily values. A TextParser is not safe for concurrent use, but a
+// single instance may be reused to parse several streams one after another.
+type TextParser struct {
+	families map[string]*MetricFamily
+	order    []string
+}
+
+// NewTextParser returns a ready-to-use TextParser.
+func NewTextParser() *TextParser {
+	return &TextParser{}
+}
+
+// TextToMetricFamilies parses r as a full exposition stream and returns the
+// MetricFamilies it describes, in the order each was first mentioned (by a
+// HELP or TYPE line or by a sample); a family named only on HELP/TYPE lines
+// is returned with no Metrics. A
+// line of just "# EOF" (as used by OpenMetrics) ends parsing early without
+// error; reaching the end of r has the same effect.
+func (p *TextParser) TextToMetricFamilies(r io.Reader) ([]*MetricFamily, error) {
+	p.families = map[string]*MetricFamily{}
+	p.order = nil
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r\n")
+		if line == "" {
+			continue
+		}
+		if line == "# EOF" {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			if err := p.parseComment(line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := p.parseSample(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]*MetricFamily, 0, len(p.order))
+	for _, name := range p.order {
+		out = append(out, p.families[name])
+	}
+	return out, nil
+}
+
+func (p *TextParser) parseComment(line string) error {
+	fields := strings.SplitN(strings.TrimPrefix(line, "#"), " ", 4)
+	// fields[0] is empty (the space after '#'), so a HELP/TYPE line looks
+	// like ["", "HELP", "<name>", "<help text>"].
+	if len(fields) < 3 {
+		return nil
+	}
+	keyword, name := fields[1], fields[2]
+
+	switch keyword {
+	case "HELP":
+		help := ""
+		if len(fields) == 4 {
+			help = unescapeHelp(fields[3])
+		}
+		p.registerFamily(name).Help = help
+	case "TYPE":
+		if len(fields) != 4 {
+			return fmt.Errorf("expfmt: invalid TYPE line %q", line)
+		}
+		t, err := parseMetricType(fields[3])
+		if err != nil {
+			return err
+		}
+		p.registerFamily(name).Type = t
+	}
+	return nil
+}
+
+func parseMetricType(s string) (MetricType, error) {
+	switch s {
+	case "counter":
+		return MetricTypeCounter, nil
+	case "gauge":
+		return MetricTypeGauge, nil
+	case "histogram":
+		return MetricTypeHistogram, nil
+	case "gaugehistogram":
+		return MetricTypeGaugeHistogram, nil
+	case "summary":
+		return MetricTypeSummary, nil
+	case "untyped":
+		return MetricTypeUntyped, nil
+	default:
+		return MetricTypeUntyped, fmt.Errorf("expfmt: unknown metric type %q", s)
+	}
+}
+
+// registerFamily returns the MetricFamily for name, creating it (as
+// Untyped, with no help text) if this is the first time it is mentioned.
+func (p *TextParser) registerFamily(name string) *MetricFamily {
+	if mf, ok := p.families[name]; ok {
+		return mf
+	}
+	mf := &MetricFamily{Name: name, Type: MetricTypeUntyped}
+	p.families[name] = mf
+	p.order = append(p.order, name)
+	return mf
+}
+
+func (p *TextParser) parseSample(line string) error {
+	nameAndLabels, rest := line, ""
+	if idx := strings.IndexByte(line, '{'); idx >= 0 {
+		end := strings.IndexByte(line[idx:], '}')
+		if end < 0 {
+			return fmt.Errorf("expfmt: unterminated label set in %q", line)
+		}
+		end += idx
+		nameAndLabels = line[:idx]
+		rest = line[idx : end+1]
+		line = strings.TrimSpace(line[end+1:])
+	} else {
+		sp := strings.IndexByte(line, ' ')
+		if sp < 0 {
+			return fmt.Errorf("expfmt: invalid sample line %q", line)
+		}
+		nameAndLabels = line[:sp]
+		line = strings.TrimSpace(line[sp:])
+	}
+	fullName := strings.TrimSpace(nameAndLabels)
+
+	labels, err := parseLabels(rest)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 1 {
+		return fmt.Errorf("expfmt: sample %q has no value", fullName)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return fmt.Errorf("expfmt: invalid sample value in %q: %w", line, err)
+	}
+	var ts *int64
+	if len(fields) > 1 {
+		t, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("expfmt: invalid timestamp in %q: %w", line, err)
+		}
+		ts = &t
+	}
+
+	mf, suffix := p.familyForSample(fullName)
+	return mf.addSample(suffix, labels, value, ts)
+}
+
+// familyForSample resolves fullName, the raw name on a sample line, to the
+// MetricFamily it belongs to and the aggregation suffix (if any) that was
+// stripped to find it.
+//
+// A "# TYPE"/"# HELP" line, if present, always precedes the samples for its
+// family and registers it under the exact name written on that line (see
+// parseComment) — so the first and most reliable match is the sample's full,
+// unmodified name. This also covers counters correctly without guessing,
+// since a well-formed exposition stream writes the "_total" suffix (or not)
+// consistently on both the TYPE line and its sample lines.
+//
+// Only once that exact match fails do we try stripping a "_bucket"/"_sum"/
+// "_count" suffix and looking up the base name, and only accept that match
+// if the family it names actually declared a Type that uses such a suffix
+// (Histogram/GaugeHistogram/Summary) — otherwise an unrelated family that
+// merely happens to end in one of those strings would be misrouted, exactly
+// as "_total" must not be stripped from a family never declared as a
+// Counter.
+func (p *TextParser) familyForSample(fullName string) (*MetricFamily, string) {
+	if mf, ok := p.families[fullName]; ok {
+		return mf, ""
+	}
+
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		base := strings.TrimSuffix(fullName, suffix)
+		if base == fullName {
+			continue
+		}
+		if mf, ok := p.families[base]; ok {
+			switch mf.Type {
+			case MetricTypeHistogram, MetricTypeGaugeHistogram, MetricTypeSummary:
+				return mf, suffix
+			}
+		}
+	}
+
+	return p.registerFamily(fullName), ""
+}
+
+func parseLabels(braced string) ([]LabelPair, error) {
+	braced = strings.TrimSpace(braced)
+	if braced == "" {
+		return nil, nil
+	}
+	braced = strings.TrimPrefix(braced, "{")
+	braced = strings.TrimSuffix(braced, "}")
+	braced = strings.TrimSpace(braced)
+	if braced == "" {
+		return nil, nil
+	}
+
+	var labels []LabelPair
+	for len(braced) > 0 {
+		eq := strings.IndexByte(braced, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("expfmt: malformed label set %q", braced)
+		}
+		name := strings.TrimSpace(braced[:eq])
+		rest := strings.TrimSpace(braced[eq+1:])
+		if len(rest) == 0 || rest[0] != '"' {
+			return nil, fmt.Errorf("expfmt: label value for %q is not quoted", name)
+		}
+		value, n, err := readQuoted(rest)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, LabelPair{Name: name, Value: value})
+
+		braced = strings.TrimSpace(rest[n:])
+		braced = strings.TrimPrefix(braced, ",")
+		braced = strings.TrimSpace(braced)
+	}
+	return labels, nil
+}
+
+// readQuoted reads a double-quoted, backslash-escaped string starting at
+// s[0] == '"' and returns its unescaped value along with the number of
+// bytes of s it consumed (including both quotes).
+func readQuoted(s string) (string, int, error) {
+	var sb strings.Builder
+	i := 1
+	for i < len(s) {
+		switch s[i] {
+		case '"':
+			return sb.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("expfmt: unterminated escape in %q", s)
+			}
+			switch s[i+1] {
+			case 'n':
+				sb.WriteByte('\n')
+			case '\\':
+				sb.WriteByte('\\')
+			case '"':
+				sb.WriteByte('"')
+			default:
+				sb.WriteByte(s[i+1])
+			}
+			i += 2
+		default:
+			sb.WriteByte(s[i])
+			i++
+		}
+	}
+	return "", 0, fmt.Errorf("expfmt: unterminated quoted string in %q", s)
+}
+
+func unescapeHelp(s string) string {
+	s = strings.ReplaceAll(s, `\n`, "\n")
+	s = strings.ReplaceAll(s, `\\`, `\`)
+	return s
+}
+
+// addSample attaches a parsed sample to mf, routing "_bucket"/"_sum"/"_count"
+// suffixed samples (and their "le"/"quantile" labels) into the right
+// Histogram or Summary based on the family's declared type.
+func (mf *MetricFamily) addSample(suffix string, labels []LabelPair, value float64, ts *int64) error {
+	switch mf.Type {
+	case MetricTypeHistogram, MetricTypeGaugeHistogram:
+		base, rest := extractLabel(labels, "le")
+		m := mf.metricFor(rest, ts)
+		if m.Histogram == nil {
+			m.Histogram = &Histogram{}
+		}
+		switch suffix {
+		case "_bucket":
+			le, err := strconv.ParseFloat(base, 64)
+			if err != nil {
+				return fmt.Errorf("expfmt: invalid le label %q: %w", base, err)
+			}
+			m.Histogram.Bucket = append(m.Histogram.Bucket, Bucket{UpperBound: le, CumulativeCount: uint64(value)})
+		case "_sum":
+			m.Histogram.SampleSum = value
+		case "_count":
+			m.Histogram.SampleCount = uint64(value)
+		}
+		return nil
+	case MetricTypeSummary:
+		base, rest := extractLabel(labels, "quantile")
+		m := mf.metricFor(rest, ts)
+		if m.Summary == nil {
+			m.Summary = &Summary{}
+		}
+		switch suffix {
+		case "":
+			q, err := strconv.ParseFloat(base, 64)
+			if err != nil {
+				return fmt.Errorf("expfmt: invalid quantile label %q: %w", base, err)
+			}
+			m.Summary.Quantile = append(m.Summary.Quantile, Quantile{Quantile: q, Value: value})
+		case "_sum":
+			m.Summary.SampleSum = value
+		case "_count":
+			m.Summary.SampleCount = uint64(value)
+		}
+		return nil
+	default:
+		m := mf.metricFor(labels, ts)
+		v := value
+		switch mf.Type {
+		case MetricTypeCounter:
+			m.Counter = &v
+		case MetricTypeGauge:
+			m.Gauge = &v
+		default:
+			m.Untyped = &v
+		}
+		return nil
+	}
+}
+
+// extractLabel removes the named label from labels, returning its value
+// (or "" if absent) and the remaining labels.
+func extractLabel(labels []LabelPair, name string) (string, []LabelPair) {
+	var value string
+	rest := make([]LabelPair, 0, len(labels))
+	for _, lp := range labels {
+		if lp.Name == name {
+			value = lp.Value
+			continue
+		}
+		rest = append(rest, lp)
+	}
+	return value, rest
+}
+
+// metricFor returns the Metric in mf matching labels, creating one if this
+// is the first sample seen for that label set.
+func (mf *MetricFamily) metricFor(labels []LabelPair, ts *int64) *Metric {
+	for _, m := range mf.Metric {
+		if labelsEqual(m.Label, labels) {
+			return m
+		}
+	}
+	m := &Metric{Label: labels, TimestampMs: ts}
+	mf.Metric = append(mf.Metric, m)
+	return m
+}
+
+// labelsEqual reports whether a and b are the same set of labels,
+// regardless of the order they were written in — labels are unordered in
+// the Prometheus data model, so two samples of the same series may list
+// them differently and must still be recognized as the same series.
+func labelsEqual(a, b []LabelPair) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	bv := make(map[string]string, len(b))
+	for _, lp := range b {
+		bv[lp.Name] = lp.Value
+	}
+	for _, lp := range a {
+		v, ok := bv[lp.Name]
+		if !ok || v != lp.Value {
+			return false
+		}
+	}
+	return true
+}
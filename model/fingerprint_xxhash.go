@@ -0,0 +1,124 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Domain-separation prefixes for the two halves of a SignedFingerprint.
+// Using distinct prefixes (rather than, say, the same digest twice) keeps
+// the two halves from degenerating into duplicates of each other.
+const (
+	signedFingerprintDomainLo = "model.SignedFingerprint.lo\x00"
+	signedFingerprintDomainHi = "model.SignedFingerprint.hi\x00"
+)
+
+// SignedFingerprint is a 128-bit metric identity computed with xxhash. Its
+// extra width makes it suitable for the high-cardinality label sets modern
+// Prometheus deployments produce, where Fingerprint's 64 bits leave a
+// meaningful chance of collision.
+type SignedFingerprint [2]uint64
+
+// String returns h as a 32-character lower-case hex string.
+func (h SignedFingerprint) String() string {
+	return fmt.Sprintf("%016x%016x", h[0], h[1])
+}
+
+// Equal reports whether h and o are the same fingerprint.
+func (h SignedFingerprint) Equal(o SignedFingerprint) bool {
+	return h == o
+}
+
+// SignedFingerprint returns a 128-bit identity for m. Label names are
+// sorted before hashing, and MetricNameLabel is always folded in first and
+// unconditionally, so two metrics with identical labels but different
+// metric names never collide.
+func (m Metric) SignedFingerprint() SignedFingerprint {
+	names := make([]LabelName, 0, len(m))
+	for ln := range m {
+		if ln != MetricNameLabel {
+			names = append(names, ln)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	lo := xxhash.New()
+	hi := xxhash.New()
+	lo.Write([]byte(signedFingerprintDomainLo))
+	hi.Write([]byte(signedFingerprintDomainHi))
+
+	writeLabelPair(lo, MetricNameLabel, m[MetricNameLabel])
+	writeLabelPair(hi, MetricNameLabel, m[MetricNameLabel])
+	for _, ln := range names {
+		writeLabelPair(lo, ln, m[ln])
+		writeLabelPair(hi, ln, m[ln])
+	}
+
+	return SignedFingerprint{lo.Sum64(), hi.Sum64()}
+}
+
+func writeLabelPair(h *xxhash.Digest, ln LabelName, lv LabelValue) {
+	h.Write([]byte(ln))
+	h.Write(separator)
+	h.Write([]byte(lv))
+	h.Write(separator)
+}
+
+// FingerprintWithLabels returns m's Fingerprint computed over only the
+// named labels, without allocating an intermediate Metric. It is meant for
+// "by (...)" grouping, where only a handful of labels out of a large Metric
+// determine series identity.
+func (m Metric) FingerprintWithLabels(names ...LabelName) Fingerprint {
+	return fingerprintSubset(m, names, true)
+}
+
+// FingerprintWithoutLabels returns m's Fingerprint computed over every
+// label except the named ones, without allocating an intermediate Metric.
+// It is meant for "without (...)" grouping.
+func (m Metric) FingerprintWithoutLabels(names ...LabelName) Fingerprint {
+	return fingerprintSubset(m, names, false)
+}
+
+// fingerprintSubset hashes the subset of m selected by names (keep them if
+// include is true, drop them otherwise) using the same FNV-1a algorithm
+// Fingerprint uses, so FingerprintWithLabels/FingerprintWithoutLabels agree
+// with Fingerprint on the metrics they happen to overlap with.
+func fingerprintSubset(m Metric, names []LabelName, include bool) Fingerprint {
+	selected := make(map[LabelName]struct{}, len(names))
+	for _, ln := range names {
+		selected[ln] = struct{}{}
+	}
+
+	keys := make([]LabelName, 0, len(m))
+	for ln := range m {
+		if _, ok := selected[ln]; ok == include {
+			keys = append(keys, ln)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	h := fnv.New64a()
+	for _, ln := range keys {
+		h.Write([]byte(ln))
+		h.Write(separator)
+		h.Write([]byte(m[ln]))
+		h.Write(separator)
+	}
+	return Fingerprint(h.Sum64())
+}
@@ -0,0 +1,105 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "testing"
+
+func benchmarkMetric() Metric {
+	return Metric{
+		MetricNameLabel: "http_requests_total",
+		"job":           "api-server",
+		"instance":      "10.0.0.1:9090",
+		"method":        "GET",
+		"status":        "200",
+		"handler":       "/api/v1/query",
+	}
+}
+
+func TestSignedFingerprintIsDeterministic(t *testing.T) {
+	m := benchmarkMetric()
+	if m.SignedFingerprint() != m.Clone().SignedFingerprint() {
+		t.Fatal("SignedFingerprint should not depend on map iteration order")
+	}
+}
+
+func TestSignedFingerprintDistinguishesMetricName(t *testing.T) {
+	a := Metric{MetricNameLabel: "foo", "instance": "1"}
+	b := Metric{MetricNameLabel: "bar", "instance": "1"}
+
+	if a.SignedFingerprint() == b.SignedFingerprint() {
+		t.Fatal("metrics with identical labels but different names must not collide")
+	}
+}
+
+func TestSignedFingerprintDistinguishesLabels(t *testing.T) {
+	a := Metric{MetricNameLabel: "foo", "instance": "1"}
+	b := Metric{MetricNameLabel: "foo", "instance": "2"}
+
+	if a.SignedFingerprint() == b.SignedFingerprint() {
+		t.Fatal("metrics with different label values must not collide")
+	}
+}
+
+func TestFingerprintWithLabelsAgreesWithFingerprint(t *testing.T) {
+	m := Metric{MetricNameLabel: "foo", "a": "1", "b": "2"}
+	full := Metric(LabelSet(m).Clone())
+
+	if got, want := m.FingerprintWithLabels(MetricNameLabel, "a", "b"), full.Fingerprint(); got != want {
+		t.Fatalf("FingerprintWithLabels over the full label set = %v, want %v", got, want)
+	}
+}
+
+func TestFingerprintWithoutLabelsAgreesWithFingerprint(t *testing.T) {
+	m := Metric{MetricNameLabel: "foo", "a": "1", "b": "2"}
+	withoutB := Metric{MetricNameLabel: "foo", "a": "1"}
+
+	if got, want := m.FingerprintWithoutLabels("b"), withoutB.Fingerprint(); got != want {
+		t.Fatalf("FingerprintWithoutLabels(\"b\") = %v, want %v", got, want)
+	}
+}
+
+func TestFingerprintWithLabelsAndWithoutLabelsAreComplementary(t *testing.T) {
+	m := Metric{MetricNameLabel: "foo", "a": "1", "b": "2"}
+
+	with := m.FingerprintWithLabels("a")
+	without := m.FingerprintWithoutLabels(MetricNameLabel, "b")
+
+	if with != without {
+		t.Fatalf("FingerprintWithLabels(%q) = %v, FingerprintWithoutLabels(rest) = %v, want equal", "a", with, without)
+	}
+}
+
+func BenchmarkMetricFingerprint(b *testing.B) {
+	m := benchmarkMetric()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.Fingerprint()
+	}
+}
+
+func BenchmarkMetricSignedFingerprint(b *testing.B) {
+	m := benchmarkMetric()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.SignedFingerprint()
+	}
+}
+
+func BenchmarkMetricFingerprintWithLabels(b *testing.B) {
+	m := benchmarkMetric()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.FingerprintWithLabels("job", "instance")
+	}
+}
@@ -85,6 +85,20 @@ func (m Metric) FastFingerprint() Fingerprint {
 type COWMetric struct {
 	Copied bool
 	Metric Metric
+
+	// overlay holds labels staged by WithLabels/Delete but not yet
+	// applied to Metric. A nil value marks a staged deletion. It is kept
+	// separate from Metric/Copied so relabeling pipelines can stage many
+	// WithLabels/Delete calls and pay for exactly one clone, in Commit, no
+	// matter how many mutations they stage.
+	overlay map[LabelName]*LabelValue
+}
+
+// NewCOWMetricFromMetric wraps m in a COWMetric. m is used as-is, so
+// callers must not mutate it afterwards except through the returned
+// COWMetric.
+func NewCOWMetricFromMetric(m Metric) *COWMetric {
+	return &COWMetric{Metric: m}
 }
 
 // Set sets a label name in the wrapped Metric to a given value and copies the
@@ -109,6 +123,79 @@ func (m *COWMetric) doCOW() {
 	}
 }
 
+// WithLabels stages the given labels to be set on Commit, without copying
+// the underlying Metric. Later calls to WithLabels or Delete for the same
+// label name override earlier ones. It returns m to allow chaining.
+func (m *COWMetric) WithLabels(ls LabelSet) *COWMetric {
+	if m.overlay == nil {
+		m.overlay = make(map[LabelName]*LabelValue, len(ls))
+	}
+	for ln, lv := range ls {
+		lv := lv
+		m.overlay[ln] = &lv
+	}
+	return m
+}
+
+// Delete stages the removal of the named labels to be applied on Commit,
+// without copying the underlying Metric. It returns m to allow chaining.
+func (m *COWMetric) Delete(names ...LabelName) *COWMetric {
+	if m.overlay == nil {
+		m.overlay = make(map[LabelName]*LabelValue, len(names))
+	}
+	for _, ln := range names {
+		m.overlay[ln] = nil
+	}
+	return m
+}
+
+// Commit applies every label staged by WithLabels and Delete to the
+// underlying Metric in a single clone, then clears the pending overlay. If
+// nothing is staged, Commit returns the existing Metric unchanged and
+// without copying it.
+func (m *COWMetric) Commit() Metric {
+	if len(m.overlay) == 0 {
+		return m.Metric
+	}
+
+	result := m.Metric.Clone()
+	for ln, lv := range m.overlay {
+		if lv == nil {
+			delete(result, ln)
+		} else {
+			result[ln] = *lv
+		}
+	}
+	m.Metric = result
+	m.Copied = true
+	m.overlay = nil
+	return m.Metric
+}
+
+// Rollback discards every label staged by WithLabels and Delete since the
+// last Commit, leaving the underlying Metric untouched.
+func (m *COWMetric) Rollback() {
+	m.overlay = nil
+}
+
+// Fingerprint returns the Fingerprint m.Metric would have after Commit. If
+// nothing is staged, it fingerprints m.Metric directly rather than paying
+// for Commit's clone.
+func (m *COWMetric) Fingerprint() Fingerprint {
+	if len(m.overlay) == 0 {
+		return m.Metric.Fingerprint()
+	}
+	result := m.Metric.Clone()
+	for ln, lv := range m.overlay {
+		if lv == nil {
+			delete(result, ln)
+		} else {
+			result[ln] = *lv
+		}
+	}
+	return result.Fingerprint()
+}
+
 // String implements fmt.Stringer.
 func (m COWMetric) String() string {
 	return m.Metric.String()
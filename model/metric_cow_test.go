@@ -0,0 +1,106 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "testing"
+
+func TestCOWMetricCommitAppliesStagedMutations(t *testing.T) {
+	orig := Metric{"job": "api", "instance": "1"}
+	cow := NewCOWMetricFromMetric(orig)
+
+	cow.WithLabels(LabelSet{"env": "prod"}).Delete("instance")
+	result := cow.Commit()
+
+	if _, ok := result["instance"]; ok {
+		t.Error("Commit should have removed the deleted label")
+	}
+	if result["env"] != "prod" {
+		t.Errorf("env = %q, want %q", result["env"], "prod")
+	}
+	if result["job"] != "api" {
+		t.Errorf("job = %q, want %q", result["job"], "api")
+	}
+
+	if _, ok := orig["env"]; ok {
+		t.Error("Commit must not mutate the original Metric")
+	}
+	if _, ok := orig["instance"]; !ok {
+		t.Error("Commit must not mutate the original Metric")
+	}
+}
+
+func TestCOWMetricCommitWithoutMutationsDoesNotCopy(t *testing.T) {
+	orig := Metric{"job": "api"}
+	cow := NewCOWMetricFromMetric(orig)
+
+	if got := cow.Commit(); !got.Equal(orig) {
+		t.Errorf("Commit with no staged mutations = %v, want %v", got, orig)
+	}
+	if cow.Copied {
+		t.Error("Commit with no staged mutations should not have copied Metric")
+	}
+}
+
+func TestCOWMetricRollbackDiscardsStagedMutations(t *testing.T) {
+	orig := Metric{"job": "api"}
+	cow := NewCOWMetricFromMetric(orig)
+
+	cow.WithLabels(LabelSet{"env": "prod"})
+	cow.Rollback()
+	result := cow.Commit()
+
+	if _, ok := result["env"]; ok {
+		t.Error("Rollback should have discarded the staged label")
+	}
+}
+
+func TestCOWMetricFingerprintMatchesCommit(t *testing.T) {
+	orig := Metric{"job": "api"}
+	cow := NewCOWMetricFromMetric(orig)
+	cow.WithLabels(LabelSet{"env": "prod"})
+
+	want := cow.Commit().Fingerprint()
+
+	cow2 := NewCOWMetricFromMetric(orig.Clone())
+	cow2.WithLabels(LabelSet{"env": "prod"})
+	if got := cow2.Fingerprint(); got != want {
+		t.Errorf("Fingerprint() = %v, want %v", got, want)
+	}
+}
+
+func TestCOWMetricFingerprintWithoutMutationsDoesNotCopy(t *testing.T) {
+	orig := Metric{"job": "api"}
+	cow := NewCOWMetricFromMetric(orig)
+
+	if got, want := cow.Fingerprint(), orig.Fingerprint(); got != want {
+		t.Errorf("Fingerprint() = %v, want %v", got, want)
+	}
+	if cow.Copied {
+		t.Error("Fingerprint with no staged mutations should not have copied Metric")
+	}
+}
+
+func TestCOWMetricSetStillClonesEagerly(t *testing.T) {
+	orig := Metric{"job": "api"}
+	cow := NewCOWMetricFromMetric(orig)
+
+	cow.Set("env", "prod")
+
+	if !cow.Copied {
+		t.Error("Set should still clone the underlying Metric immediately")
+	}
+	if _, ok := orig["env"]; ok {
+		t.Error("Set must not mutate the original Metric")
+	}
+}
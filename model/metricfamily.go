@@ -0,0 +1,370 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MetricType describes the type of a MetricFamily, following the vocabulary
+// used by the Prometheus exposition formats. It is a distinct type from
+// expfmt.MetricType (model/expfmt/metricfamily.go): this one describes a
+// MetricFamily reassembled in-memory from a flat Sample stream by
+// GroupByFamily, while expfmt.MetricFamily describes one being read from or
+// written to an exposition format. The two share an ordinal order (Untyped
+// is always zero) but are otherwise not interchangeable.
+type MetricType int
+
+// Valid values for MetricType.
+const (
+	MetricTypeUntyped MetricType = iota
+	MetricTypeCounter
+	MetricTypeGauge
+	MetricTypeHistogram
+	MetricTypeGaugeHistogram
+	MetricTypeSummary
+)
+
+// String returns the lower-case exposition-format name of the type.
+func (t MetricType) String() string {
+	switch t {
+	case MetricTypeCounter:
+		return "counter"
+	case MetricTypeGauge:
+		return "gauge"
+	case MetricTypeHistogram:
+		return "histogram"
+	case MetricTypeGaugeHistogram:
+		return "gaugehistogram"
+	case MetricTypeSummary:
+		return "summary"
+	default:
+		return "untyped"
+	}
+}
+
+// Bucket is one cumulative histogram bucket, as parsed from a "_bucket"
+// series and its "le" label.
+type Bucket struct {
+	UpperBound      float64
+	CumulativeCount uint64
+}
+
+// QuantileValue is one summary quantile observation, as parsed from a bare
+// series and its "quantile" label.
+type QuantileValue struct {
+	Quantile float64
+	Value    SampleValue
+}
+
+// HistogramSeries is one histogram observation: the label set shared by its
+// buckets (with the aggregation label removed), the buckets themselves, and
+// the matching "_sum"/"_count" series.
+type HistogramSeries struct {
+	Metric  Metric
+	Buckets []Bucket
+	Sum     SampleValue
+	Count   uint64
+}
+
+// SummarySeries is one summary observation, analogous to HistogramSeries.
+type SummarySeries struct {
+	Metric    Metric
+	Quantiles []QuantileValue
+	Sum       SampleValue
+	Count     uint64
+}
+
+// MetricFamily groups every Sample sharing a metric name together with the
+// type and help text that describe them. For Counter, Gauge and Untyped
+// families, Samples holds one entry per series. For Histogram and Summary
+// families, the per-series buckets/quantiles and their "_sum"/"_count"
+// siblings are reassembled into Histograms/Summaries instead, and Samples
+// is empty.
+type MetricFamily struct {
+	Name       LabelValue
+	Help       string
+	Type       MetricType
+	Samples    []Sample
+	Histograms []*HistogramSeries
+	Summaries  []*SummarySeries
+}
+
+// GroupByFamily reassembles a flat stream of Samples, such as a scraper
+// reads off the wire, into MetricFamily values. It recognizes the
+// conventional "_bucket"/"_sum"/"_count" name suffixes together with the
+// "le"/"quantile" labels that the Prometheus text and OpenMetrics formats
+// use to describe histograms and summaries, and groups the matching series
+// back into a single HistogramSeries or SummarySeries. Families that do not
+// match that pattern are reported as MetricTypeUntyped with one Sample per
+// series. GroupByFamily does not itself know the declared metric type, so
+// callers that have that information (e.g. from "# TYPE" comments) should
+// set MetricFamily.Type and MetricFamily.Help afterwards. It returns an
+// error if any "le" or "quantile" label cannot be parsed as a float.
+func GroupByFamily(samples []Sample) ([]*MetricFamily, error) {
+	byName := map[LabelValue][]Sample{}
+	var names []LabelValue
+	for _, s := range samples {
+		name := s.Metric[MetricNameLabel]
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], s)
+	}
+
+	isHistogram := map[LabelValue]bool{}
+	isSummary := map[LabelValue]bool{}
+	for _, name := range names {
+		base := LabelValue(trimSuffix(string(name), "_bucket"))
+		if base != name {
+			if _, ok := byName[base+"_sum"]; ok {
+				if _, ok := byName[base+"_count"]; ok {
+					isHistogram[base] = true
+				}
+			}
+			continue
+		}
+		if _, ok := byName[name+"_sum"]; ok {
+			if _, ok := byName[name+"_count"]; ok {
+				if sampleSetHasLabel(byName[name], QuantileLabel) {
+					isSummary[name] = true
+				}
+			}
+		}
+	}
+
+	// A "_bucket"/"_sum"/"_count" series belongs to some other family's
+	// aggregation and must not also be reported on its own.
+	aggregated := map[LabelValue]bool{}
+	for base := range isHistogram {
+		aggregated[base+"_sum"] = true
+		aggregated[base+"_count"] = true
+	}
+	for base := range isSummary {
+		aggregated[base+"_sum"] = true
+		aggregated[base+"_count"] = true
+	}
+
+	// Histogram families never appear under their own base name in names
+	// (only their "_bucket"/"_sum"/"_count" components do), so a
+	// histogram's base name has to be derived from each raw sample name
+	// rather than looked up directly in names. A summary's quantile
+	// samples do carry the base name, so summaries are looked up directly.
+	var families []*MetricFamily
+	for _, name := range names {
+		base := LabelValue(trimSuffix(string(name), "_bucket"))
+		switch {
+		case base != name && isHistogram[base]:
+			mf, err := groupHistogram(base, byName[name], byName[base+"_sum"], byName[base+"_count"])
+			if err != nil {
+				return nil, err
+			}
+			families = append(families, mf)
+		case isSummary[name]:
+			mf, err := groupSummary(name, byName[name], byName[name+"_sum"], byName[name+"_count"])
+			if err != nil {
+				return nil, err
+			}
+			families = append(families, mf)
+		case aggregated[name]:
+			// consumed as part of a histogram or summary above
+		default:
+			families = append(families, &MetricFamily{
+				Name:    name,
+				Type:    MetricTypeUntyped,
+				Samples: byName[name],
+			})
+		}
+	}
+
+	return families, nil
+}
+
+func trimSuffix(s, suffix string) string {
+	if len(s) > len(suffix) && s[len(s)-len(suffix):] == suffix {
+		return s[:len(s)-len(suffix)]
+	}
+	return s
+}
+
+func sampleSetHasLabel(samples []Sample, name LabelName) bool {
+	for _, s := range samples {
+		if _, ok := s.Metric[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func groupHistogram(name LabelValue, buckets, sums, counts []Sample) (*MetricFamily, error) {
+	series := map[string]*HistogramSeries{}
+	var order []string
+
+	for _, s := range buckets {
+		base := s.Metric.Clone()
+		le := base[BucketLabel]
+		delete(base, BucketLabel)
+		delete(base, MetricNameLabel)
+		key := base.String()
+		hs, ok := series[key]
+		if !ok {
+			hs = &HistogramSeries{Metric: base}
+			series[key] = hs
+			order = append(order, key)
+		}
+		upperBound, err := parseFloatLabel(le)
+		if err != nil {
+			return nil, fmt.Errorf("model: histogram family %q has an invalid %q label %q: %w", name, BucketLabel, le, err)
+		}
+		hs.Buckets = append(hs.Buckets, Bucket{UpperBound: upperBound, CumulativeCount: uint64(s.Value)})
+	}
+	for _, s := range sums {
+		key := baseKey(s.Metric)
+		if hs, ok := series[key]; ok {
+			hs.Sum = s.Value
+		}
+	}
+	for _, s := range counts {
+		key := baseKey(s.Metric)
+		if hs, ok := series[key]; ok {
+			hs.Count = uint64(s.Value)
+		}
+	}
+
+	mf := &MetricFamily{Name: name, Type: MetricTypeHistogram}
+	for _, key := range order {
+		hs := series[key]
+		sort.Slice(hs.Buckets, func(i, j int) bool { return hs.Buckets[i].UpperBound < hs.Buckets[j].UpperBound })
+		mf.Histograms = append(mf.Histograms, hs)
+	}
+	return mf, nil
+}
+
+func groupSummary(name LabelValue, quantiles, sums, counts []Sample) (*MetricFamily, error) {
+	series := map[string]*SummarySeries{}
+	var order []string
+
+	for _, s := range quantiles {
+		base := s.Metric.Clone()
+		q := base[QuantileLabel]
+		delete(base, QuantileLabel)
+		delete(base, MetricNameLabel)
+		key := base.String()
+		ss, ok := series[key]
+		if !ok {
+			ss = &SummarySeries{Metric: base}
+			series[key] = ss
+			order = append(order, key)
+		}
+		quantile, err := parseFloatLabel(q)
+		if err != nil {
+			return nil, fmt.Errorf("model: summary family %q has an invalid %q label %q: %w", name, QuantileLabel, q, err)
+		}
+		ss.Quantiles = append(ss.Quantiles, QuantileValue{Quantile: quantile, Value: s.Value})
+	}
+	for _, s := range sums {
+		key := baseKey(s.Metric)
+		if ss, ok := series[key]; ok {
+			ss.Sum = s.Value
+		}
+	}
+	for _, s := range counts {
+		key := baseKey(s.Metric)
+		if ss, ok := series[key]; ok {
+			ss.Count = uint64(s.Value)
+		}
+	}
+
+	mf := &MetricFamily{Name: name, Type: MetricTypeSummary}
+	for _, key := range order {
+		ss := series[key]
+		sort.Slice(ss.Quantiles, func(i, j int) bool { return ss.Quantiles[i].Quantile < ss.Quantiles[j].Quantile })
+		mf.Summaries = append(mf.Summaries, ss)
+	}
+	return mf, nil
+}
+
+// baseKey returns the String() of m's label set with MetricNameLabel
+// removed, used to match a "_sum"/"_count" series back to the bucket or
+// quantile group it belongs to.
+func baseKey(m Metric) string {
+	base := m.Clone()
+	delete(base, MetricNameLabel)
+	return base.String()
+}
+
+func parseFloatLabel(lv LabelValue) (float64, error) {
+	var f float64
+	_, err := fmt.Sscanf(string(lv), "%g", &f)
+	return f, err
+}
+
+// Validate checks that fam is internally consistent: every Histogram or
+// Summary series must have strictly increasing bucket upper bounds or
+// quantiles respectively; every member of Histograms/Summaries must share
+// its label set with every other member modulo the aggregation label
+// (BucketLabel/QuantileLabel) — i.e. none may still carry that label, and
+// no two may share the same label set once it is removed, since that would
+// mean two distinct series were merged into one; and Samples/Histograms/
+// Summaries must match the declared Type.
+func (fam *MetricFamily) Validate() error {
+	switch fam.Type {
+	case MetricTypeHistogram, MetricTypeGaugeHistogram:
+		if len(fam.Samples) > 0 {
+			return fmt.Errorf("model: histogram family %q has plain samples", fam.Name)
+		}
+		seen := map[string]bool{}
+		for _, hs := range fam.Histograms {
+			if _, ok := hs.Metric[BucketLabel]; ok {
+				return fmt.Errorf("model: histogram family %q has a series whose label set still carries %q", fam.Name, BucketLabel)
+			}
+			key := hs.Metric.String()
+			if seen[key] {
+				return fmt.Errorf("model: histogram family %q has two series with the same label set modulo %q", fam.Name, BucketLabel)
+			}
+			seen[key] = true
+			for i := 1; i < len(hs.Buckets); i++ {
+				if hs.Buckets[i].UpperBound <= hs.Buckets[i-1].UpperBound {
+					return fmt.Errorf("model: histogram family %q has non-increasing bucket bounds", fam.Name)
+				}
+			}
+		}
+	case MetricTypeSummary:
+		if len(fam.Samples) > 0 {
+			return fmt.Errorf("model: summary family %q has plain samples", fam.Name)
+		}
+		seen := map[string]bool{}
+		for _, ss := range fam.Summaries {
+			if _, ok := ss.Metric[QuantileLabel]; ok {
+				return fmt.Errorf("model: summary family %q has a series whose label set still carries %q", fam.Name, QuantileLabel)
+			}
+			key := ss.Metric.String()
+			if seen[key] {
+				return fmt.Errorf("model: summary family %q has two series with the same label set modulo %q", fam.Name, QuantileLabel)
+			}
+			seen[key] = true
+			for i := 1; i < len(ss.Quantiles); i++ {
+				if ss.Quantiles[i].Quantile <= ss.Quantiles[i-1].Quantile {
+					return fmt.Errorf("model: summary family %q has non-increasing quantiles", fam.Name)
+				}
+			}
+		}
+	default:
+		if len(fam.Histograms) > 0 || len(fam.Summaries) > 0 {
+			return fmt.Errorf("model: %s family %q has histogram or summary series", fam.Type, fam.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "testing"
+
+func sample(name string, extra LabelSet, value float64) Sample {
+	m := Metric{MetricNameLabel: LabelValue(name)}
+	for ln, lv := range extra {
+		m[ln] = lv
+	}
+	return Sample{Metric: m, Value: SampleValue(value)}
+}
+
+func TestGroupByFamilyHistogram(t *testing.T) {
+	samples := []Sample{
+		sample("req_duration_seconds_bucket", LabelSet{"le": "0.1"}, 5),
+		sample("req_duration_seconds_bucket", LabelSet{"le": "0.5"}, 9),
+		sample("req_duration_seconds_bucket", LabelSet{"le": "+Inf"}, 10),
+		sample("req_duration_seconds_sum", nil, 3.2),
+		sample("req_duration_seconds_count", nil, 10),
+	}
+
+	fams, err := GroupByFamily(samples)
+	if err != nil {
+		t.Fatalf("GroupByFamily: %v", err)
+	}
+	if len(fams) != 1 {
+		t.Fatalf("GroupByFamily returned %d families, want 1", len(fams))
+	}
+
+	fam := fams[0]
+	if fam.Name != "req_duration_seconds" {
+		t.Fatalf("family name = %q, want %q", fam.Name, "req_duration_seconds")
+	}
+	if fam.Type != MetricTypeHistogram {
+		t.Fatalf("family type = %v, want %v", fam.Type, MetricTypeHistogram)
+	}
+	if len(fam.Histograms) != 1 {
+		t.Fatalf("got %d histogram series, want 1", len(fam.Histograms))
+	}
+
+	hs := fam.Histograms[0]
+	if len(hs.Buckets) != 3 {
+		t.Fatalf("got %d buckets, want 3", len(hs.Buckets))
+	}
+	if hs.Sum != 3.2 {
+		t.Errorf("sum = %v, want 3.2", hs.Sum)
+	}
+	if hs.Count != 10 {
+		t.Errorf("count = %v, want 10", hs.Count)
+	}
+	if err := fam.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestGroupByFamilySummary(t *testing.T) {
+	samples := []Sample{
+		sample("rpc_latency_seconds", LabelSet{"quantile": "0.5"}, 0.2),
+		sample("rpc_latency_seconds", LabelSet{"quantile": "0.9"}, 0.5),
+		sample("rpc_latency_seconds_sum", nil, 12.5),
+		sample("rpc_latency_seconds_count", nil, 50),
+	}
+
+	fams, err := GroupByFamily(samples)
+	if err != nil {
+		t.Fatalf("GroupByFamily: %v", err)
+	}
+	if len(fams) != 1 {
+		t.Fatalf("GroupByFamily returned %d families, want 1", len(fams))
+	}
+
+	fam := fams[0]
+	if fam.Type != MetricTypeSummary {
+		t.Fatalf("family type = %v, want %v", fam.Type, MetricTypeSummary)
+	}
+	if len(fam.Summaries) != 1 || len(fam.Summaries[0].Quantiles) != 2 {
+		t.Fatalf("unexpected summary grouping: %+v", fam.Summaries)
+	}
+	if err := fam.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestGroupByFamilyUntyped(t *testing.T) {
+	samples := []Sample{
+		sample("up", LabelSet{"job": "api"}, 1),
+		sample("up", LabelSet{"job": "db"}, 0),
+	}
+
+	fams, err := GroupByFamily(samples)
+	if err != nil {
+		t.Fatalf("GroupByFamily: %v", err)
+	}
+	if len(fams) != 1 {
+		t.Fatalf("GroupByFamily returned %d families, want 1", len(fams))
+	}
+	if fams[0].Type != MetricTypeUntyped || len(fams[0].Samples) != 2 {
+		t.Fatalf("unexpected family: %+v", fams[0])
+	}
+}
+
+func TestGroupByFamilyMultipleSeriesPerHistogram(t *testing.T) {
+	samples := []Sample{
+		sample("req_duration_seconds_bucket", LabelSet{"le": "0.5", "job": "a"}, 1),
+		sample("req_duration_seconds_bucket", LabelSet{"le": "+Inf", "job": "a"}, 2),
+		sample("req_duration_seconds_sum", LabelSet{"job": "a"}, 0.7),
+		sample("req_duration_seconds_count", LabelSet{"job": "a"}, 2),
+		sample("req_duration_seconds_bucket", LabelSet{"le": "0.5", "job": "b"}, 3),
+		sample("req_duration_seconds_bucket", LabelSet{"le": "+Inf", "job": "b"}, 5),
+		sample("req_duration_seconds_sum", LabelSet{"job": "b"}, 1.1),
+		sample("req_duration_seconds_count", LabelSet{"job": "b"}, 5),
+	}
+
+	fams, err := GroupByFamily(samples)
+	if err != nil {
+		t.Fatalf("GroupByFamily: %v", err)
+	}
+	if len(fams) != 1 {
+		t.Fatalf("GroupByFamily returned %d families, want 1", len(fams))
+	}
+	if len(fams[0].Histograms) != 2 {
+		t.Fatalf("got %d histogram series, want 2 (one per job)", len(fams[0].Histograms))
+	}
+	if err := fams[0].Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestGroupByFamilyRejectsMalformedLe(t *testing.T) {
+	samples := []Sample{
+		sample("req_duration_seconds_bucket", LabelSet{"le": "not-a-number"}, 5),
+		sample("req_duration_seconds_sum", nil, 3.2),
+		sample("req_duration_seconds_count", nil, 10),
+	}
+	if _, err := GroupByFamily(samples); err == nil {
+		t.Fatal("GroupByFamily should reject a malformed le label instead of silently defaulting it to 0")
+	}
+}
+
+func TestGroupByFamilyRejectsMalformedQuantile(t *testing.T) {
+	samples := []Sample{
+		sample("rpc_latency_seconds", LabelSet{"quantile": "not-a-number"}, 0.2),
+		sample("rpc_latency_seconds_sum", nil, 12.5),
+		sample("rpc_latency_seconds_count", nil, 50),
+	}
+	if _, err := GroupByFamily(samples); err == nil {
+		t.Fatal("GroupByFamily should reject a malformed quantile label instead of silently defaulting it to 0")
+	}
+}
+
+func TestMetricFamilyValidateRejectsNonIncreasingBuckets(t *testing.T) {
+	fam := &MetricFamily{
+		Name: "bad_bucket",
+		Type: MetricTypeHistogram,
+		Histograms: []*HistogramSeries{{
+			Buckets: []Bucket{{UpperBound: 1}, {UpperBound: 1}},
+		}},
+	}
+	if err := fam.Validate(); err == nil {
+		t.Fatal("Validate should reject non-increasing bucket bounds")
+	}
+}
+
+func TestMetricFamilyValidateRejectsDuplicateLabelSets(t *testing.T) {
+	shared := Metric{"job": "a"}
+	fam := &MetricFamily{
+		Name: "dup",
+		Type: MetricTypeHistogram,
+		Histograms: []*HistogramSeries{
+			{Metric: shared, Buckets: []Bucket{{UpperBound: 1}}},
+			{Metric: shared, Buckets: []Bucket{{UpperBound: 1}}},
+		},
+	}
+	if err := fam.Validate(); err == nil {
+		t.Fatal("Validate should reject two series with the same label set")
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MetricNameRE matches valid metric names, and LabelNameRE matches valid
+// label names, per the Prometheus data model.
+var (
+	MetricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	LabelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// IsValid reports whether ln is a syntactically valid label name: non-empty
+// and matching LabelNameRE. It says nothing about whether ln is reserved;
+// use IsReserved for that.
+func (ln LabelName) IsValid() bool {
+	if len(ln) == 0 {
+		return false
+	}
+	return LabelNameRE.MatchString(string(ln))
+}
+
+// IsReserved reports whether ln begins with ReservedLabelPrefix. Reserved
+// label names are used internally (by service discovery, the scrape
+// pipeline, relabeling, etc.) and are stripped before a target's labels are
+// exposed, so user-supplied metrics should not normally carry them.
+func (ln LabelName) IsReserved() bool {
+	return strings.HasPrefix(string(ln), ReservedLabelPrefix)
+}
+
+// validationOptions configures Metric.Validate.
+type validationOptions struct {
+	allowReserved bool
+}
+
+// ValidationOption customizes a call to Metric.Validate.
+type ValidationOption func(*validationOptions)
+
+// AllowReserved permits label names beginning with ReservedLabelPrefix to
+// pass Validate instead of being rejected. Pass it when validating metrics
+// that are still going through the scrape/relabeling pipeline, where
+// reserved meta-labels are expected to be present.
+func AllowReserved() ValidationOption {
+	return func(o *validationOptions) { o.allowReserved = true }
+}
+
+// Validate reports whether m is well-formed: its metric name, if present,
+// matches MetricNameRE; every label name matches LabelNameRE; every label
+// value is valid UTF-8; and, unless AllowReserved is passed, no label name
+// begins with ReservedLabelPrefix. Per the Prometheus data model, a label
+// with an empty value is equivalent to the label being absent, so empty
+// values are skipped rather than rejected.
+func (m Metric) Validate(opts ...ValidationOption) error {
+	var o validationOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if name, ok := m[MetricNameLabel]; ok && name != "" {
+		if !MetricNameRE.MatchString(string(name)) {
+			return fmt.Errorf("model: invalid metric name %q", name)
+		}
+	}
+
+	for ln, lv := range m {
+		if ln == MetricNameLabel || lv == "" {
+			continue
+		}
+		if !ln.IsValid() {
+			return fmt.Errorf("model: invalid label name %q", ln)
+		}
+		if !o.allowReserved && ln.IsReserved() {
+			return fmt.Errorf("model: label name %q uses reserved prefix %q", ln, ReservedLabelPrefix)
+		}
+		if !utf8.ValidString(string(lv)) {
+			return fmt.Errorf("model: label %q has a value that is not valid UTF-8", ln)
+		}
+	}
+	return nil
+}
+
+// protectedLabels are the labels Prometheus' own scrape pipeline adds to
+// every scraped metric (the target's job and instance). Sanitize renames
+// any user-supplied label that collides with one of these.
+var protectedLabels = []LabelName{JobLabel, InstanceLabel}
+
+// Sanitize returns a copy of m in which any user-supplied label colliding
+// with a label Prometheus adds itself after scraping (job, instance) has
+// been renamed by prefixing it with ExportedLabelPrefix, repeating the
+// prefix if necessary to avoid a further collision. This lets ingestion
+// pipelines merge scraped metrics with server-added labels without either
+// clobbering the other.
+func (m Metric) Sanitize() Metric {
+	out := m.Clone()
+	for _, ln := range protectedLabels {
+		lv, ok := m[ln]
+		if !ok {
+			continue
+		}
+		exported := ExportedLabelPrefix + ln
+		for {
+			if _, exists := m[exported]; !exists {
+				break
+			}
+			exported = ExportedLabelPrefix + exported
+		}
+		delete(out, ln)
+		out[exported] = lv
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+// Copyright 2013 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "testing"
+
+func TestLabelNameIsValid(t *testing.T) {
+	cases := []struct {
+		name LabelName
+		want bool
+	}{
+		{"job", true},
+		{"__name__", true},
+		{"_foo_bar9", true},
+		{"", false},
+		{"9job", false},
+		{"job-name", false},
+		{"job name", false},
+	}
+	for _, c := range cases {
+		if got := c.name.IsValid(); got != c.want {
+			t.Errorf("LabelName(%q).IsValid() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestLabelNameIsReserved(t *testing.T) {
+	if !LabelName("__address__").IsReserved() {
+		t.Error("__address__ should be reserved")
+	}
+	if LabelName("job").IsReserved() {
+		t.Error("job should not be reserved")
+	}
+}
+
+func TestMetricValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		m       Metric
+		opts    []ValidationOption
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			m:    Metric{MetricNameLabel: "http_requests_total", "job": "api"},
+		},
+		{
+			name:    "invalid metric name",
+			m:       Metric{MetricNameLabel: "1_http_requests"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid label name",
+			m:       Metric{MetricNameLabel: "foo", "bad-label": "x"},
+			wantErr: true,
+		},
+		{
+			name:    "reserved label rejected by default",
+			m:       Metric{MetricNameLabel: "foo", "__meta_x": "x"},
+			wantErr: true,
+		},
+		{
+			name: "reserved label allowed with AllowReserved",
+			m:    Metric{MetricNameLabel: "foo", "__meta_x": "x"},
+			opts: []ValidationOption{AllowReserved()},
+		},
+		{
+			name: "empty label value is ignored",
+			m:    Metric{MetricNameLabel: "foo", "bad-label": ""},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.m.Validate(c.opts...)
+			if (err != nil) != c.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetricSanitize(t *testing.T) {
+	m := Metric{
+		MetricNameLabel: "up",
+		"job":           "scraped-job",
+		"instance":      "scraped-instance",
+		"region":        "us-east",
+	}
+
+	out := m.Sanitize()
+
+	if out["exported_job"] != "scraped-job" {
+		t.Errorf("exported_job = %q, want %q", out["exported_job"], "scraped-job")
+	}
+	if out["exported_instance"] != "scraped-instance" {
+		t.Errorf("exported_instance = %q, want %q", out["exported_instance"], "scraped-instance")
+	}
+	if _, ok := out["job"]; ok {
+		t.Error("Sanitize should have renamed the colliding job label")
+	}
+	if out["region"] != "us-east" {
+		t.Errorf("region = %q, want %q", out["region"], "us-east")
+	}
+	if out[MetricNameLabel] != "up" {
+		t.Error("Sanitize must not touch the metric name")
+	}
+
+	if _, ok := m["job"]; !ok {
+		t.Error("Sanitize must not mutate the original Metric")
+	}
+}
+
+func TestMetricSanitizeAvoidsDoubleCollision(t *testing.T) {
+	m := Metric{
+		MetricNameLabel: "up",
+		"job":           "scraped-job",
+		"exported_job":  "already-here",
+	}
+
+	out := m.Sanitize()
+
+	if out["exported_exported_job"] != "scraped-job" {
+		t.Errorf("exported_exported_job = %q, want %q", out["exported_exported_job"], "scraped-job")
+	}
+	if out["exported_job"] != "already-here" {
+		t.Errorf("exported_job = %q, want %q", out["exported_job"], "already-here")
+	}
+}